@@ -0,0 +1,208 @@
+package framebuffer
+
+import "image/color"
+
+// pixelCodec knows how to decode/encode a single pixel of a particular
+// on-device format to and from color.RGBA. FrameBuffer selects one at
+// Open time based on the fb_var_screeninfo/fb_fix_screeninfo the driver
+// reports, so callers never have to care whether they're talking to
+// 16bpp RGB565 panel or a 32bpp HDMI output.
+type pixelCodec interface {
+	bytesPerPixel() int
+	colorModel() color.Model
+	decode(px []byte) color.RGBA
+	encode(px []byte, c color.RGBA)
+
+	// hasAlpha reports whether decode() returns a real alpha channel
+	// read from the device rather than the hardcoded A: 0 every
+	// non-alpha format uses. Snapshot/ReadPixels use this to force
+	// alpha opaque instead of producing fully-transparent RGBA.
+	hasAlpha() bool
+}
+
+// codecColorModel implements color.Model by round-tripping a color
+// through a pixelCodec's own encode/decode, so ColorModel() reflects the
+// precision the underlying device format actually has (e.g. RGB565's 5/6/5
+// bits, or BGRA8888 dropping alpha) instead of claiming full 8-bit RGBA
+// for every format.
+type codecColorModel struct {
+	codec pixelCodec
+}
+
+func (m codecColorModel) Convert(c color.Color) color.Color {
+	if rgba, ok := c.(color.RGBA); ok {
+		return m.roundTrip(rgba)
+	}
+	r, g, b, a := c.RGBA()
+	return m.roundTrip(color.RGBA{R: uint8(r >> 8), G: uint8(g >> 8), B: uint8(b >> 8), A: uint8(a >> 8)})
+}
+
+func (m codecColorModel) roundTrip(c color.RGBA) color.Color {
+	px := make([]byte, m.codec.bytesPerPixel())
+	m.codec.encode(px, c)
+	return m.codec.decode(px)
+}
+
+// codecBGRA8888 is the format this package has always assumed: 32bpp,
+// byte order B, G, R, X (alpha ignored on read, X byte left untouched on
+// write).
+type codecBGRA8888 struct{}
+
+func (codecBGRA8888) bytesPerPixel() int        { return 4 }
+func (c codecBGRA8888) colorModel() color.Model { return codecColorModel{c} }
+func (codecBGRA8888) hasAlpha() bool            { return false }
+
+func (codecBGRA8888) decode(px []byte) color.RGBA {
+	return color.RGBA{R: px[red], G: px[green], B: px[blue], A: 0}
+}
+
+func (codecBGRA8888) encode(px []byte, c color.RGBA) {
+	px[red] = c.R
+	px[green] = c.G
+	px[blue] = c.B
+}
+
+// codecRGBA8888 is the mirror byte order: R, G, B, A.
+type codecRGBA8888 struct{}
+
+func (codecRGBA8888) bytesPerPixel() int      { return 4 }
+func (codecRGBA8888) colorModel() color.Model { return color.RGBAModel }
+func (codecRGBA8888) hasAlpha() bool          { return true }
+
+func (codecRGBA8888) decode(px []byte) color.RGBA {
+	return color.RGBA{R: px[0], G: px[1], B: px[2], A: px[3]}
+}
+
+func (codecRGBA8888) encode(px []byte, c color.RGBA) {
+	px[0] = c.R
+	px[1] = c.G
+	px[2] = c.B
+	px[3] = c.A
+}
+
+// codecRGB24 handles 24bpp packed RGB, with no padding byte.
+type codecRGB24 struct{}
+
+func (codecRGB24) bytesPerPixel() int        { return 3 }
+func (c codecRGB24) colorModel() color.Model { return codecColorModel{c} }
+func (codecRGB24) hasAlpha() bool            { return false }
+
+func (codecRGB24) decode(px []byte) color.RGBA {
+	return color.RGBA{R: px[0], G: px[1], B: px[2], A: 0}
+}
+
+func (codecRGB24) encode(px []byte, c color.RGBA) {
+	px[0] = c.R
+	px[1] = c.G
+	px[2] = c.B
+}
+
+// codecRGB565 handles the 16bpp format most common on small SPI/DSI
+// panels (e.g. the Raspberry Pi's default console framebuffer): 5 bits
+// red, 6 bits green, 5 bits blue, packed little-endian into a uint16.
+type codecRGB565 struct{}
+
+func (codecRGB565) bytesPerPixel() int        { return 2 }
+func (c codecRGB565) colorModel() color.Model { return codecColorModel{c} }
+func (codecRGB565) hasAlpha() bool            { return false }
+
+func (codecRGB565) decode(px []byte) color.RGBA {
+	v := uint16(px[0]) | uint16(px[1])<<8
+	r := uint8(v>>11) & 0x1f
+	g := uint8(v>>5) & 0x3f
+	b := uint8(v) & 0x1f
+	return color.RGBA{
+		R: r<<3 | r>>2,
+		G: g<<2 | g>>4,
+		B: b<<3 | b>>2,
+		A: 0,
+	}
+}
+
+func (codecRGB565) encode(px []byte, c color.RGBA) {
+	v := uint16(c.R>>3)<<11 | uint16(c.G>>2)<<5 | uint16(c.B>>3)
+	px[0] = byte(v)
+	px[1] = byte(v >> 8)
+}
+
+// selectCodec picks a pixelCodec matching the mode the driver reported
+// in var. Formats that don't match one of the common layouts we have a
+// dedicated codec for fall back to codecBitfields, which is slower but
+// handles any bits_per_pixel/offset/length combination the hardware
+// throws at us.
+func selectCodec(vi fbVarScreenInfo) pixelCodec {
+	switch {
+	case vi.BitsPerPixel == 16 && vi.Red.Length == 5 && vi.Green.Length == 6 && vi.Blue.Length == 5:
+		return codecRGB565{}
+	case vi.BitsPerPixel == 32 && vi.Red.Offset == 16 && vi.Green.Offset == 8 && vi.Blue.Offset == 0:
+		return codecBGRA8888{}
+	case vi.BitsPerPixel == 32 && vi.Red.Offset == 0 && vi.Green.Offset == 8 && vi.Blue.Offset == 16:
+		return codecRGBA8888{}
+	case vi.BitsPerPixel == 24 && vi.Red.Offset == 0 && vi.Green.Offset == 8 && vi.Blue.Offset == 16:
+		return codecRGB24{}
+	default:
+		return codecBitfields{vi: vi, bpp: int(vi.BitsPerPixel+7) / 8}
+	}
+}
+
+// codecBitfields is the generic fallback: it reads bits_per_pixel/8
+// little-endian bytes into a uint32 and extracts/inserts each channel
+// using the offset/length bitfields the driver reported, exactly as
+// described in linux/fb.h's fb_bitfield doc comment.
+type codecBitfields struct {
+	vi  fbVarScreenInfo
+	bpp int
+}
+
+func (c codecBitfields) bytesPerPixel() int      { return c.bpp }
+func (c codecBitfields) colorModel() color.Model { return codecColorModel{c} }
+func (c codecBitfields) hasAlpha() bool          { return false }
+
+func (c codecBitfields) decode(px []byte) color.RGBA {
+	var v uint32
+	for i := 0; i < c.bpp && i < 4; i++ {
+		v |= uint32(px[i]) << (8 * uint(i))
+	}
+	return color.RGBA{
+		R: extractChannel(v, c.vi.Red),
+		G: extractChannel(v, c.vi.Green),
+		B: extractChannel(v, c.vi.Blue),
+		A: 0,
+	}
+}
+
+func (c codecBitfields) encode(px []byte, col color.RGBA) {
+	var v uint32
+	v |= insertChannel(col.R, c.vi.Red)
+	v |= insertChannel(col.G, c.vi.Green)
+	v |= insertChannel(col.B, c.vi.Blue)
+	for i := 0; i < c.bpp && i < 4; i++ {
+		px[i] = byte(v >> (8 * uint(i)))
+	}
+}
+
+func extractChannel(v uint32, bf fbBitfield) uint8 {
+	if bf.Length == 0 {
+		return 0
+	}
+	mask := uint32(1)<<bf.Length - 1
+	raw := (v >> bf.Offset) & mask
+	if bf.Length >= 8 {
+		return uint8(raw >> (bf.Length - 8))
+	}
+	return uint8(raw << (8 - bf.Length))
+}
+
+func insertChannel(c uint8, bf fbBitfield) uint32 {
+	if bf.Length == 0 {
+		return 0
+	}
+	var raw uint32
+	if bf.Length >= 8 {
+		raw = uint32(c) << (bf.Length - 8)
+	} else {
+		raw = uint32(c) >> (8 - bf.Length)
+	}
+	mask := uint32(1)<<bf.Length - 1
+	return (raw & mask) << bf.Offset
+}
@@ -0,0 +1,79 @@
+package framebuffer
+
+import (
+	"image/color"
+	"testing"
+)
+
+func TestCodecRGB565RoundTrip(t *testing.T) {
+	c := codecRGB565{}
+	px := make([]byte, c.bytesPerPixel())
+	c.encode(px, color.RGBA{R: 0xff, G: 0x80, B: 0x10, A: 0xff})
+	got := c.decode(px)
+	want := color.RGBA{R: 0xff, G: 0x82, B: 0x10, A: 0}
+	if got != want {
+		t.Errorf("RGB565 round trip = %+v, want %+v", got, want)
+	}
+}
+
+func TestCodecRGB565ColorModelQuantizes(t *testing.T) {
+	c := codecRGB565{}
+	converted := c.colorModel().Convert(color.RGBA{R: 0xff, G: 0x80, B: 0x10, A: 0xff})
+	if converted == (color.RGBA{R: 0xff, G: 0x80, B: 0x10, A: 0xff}) {
+		t.Errorf("ColorModel().Convert() returned the input unchanged; RGB565 should lose precision")
+	}
+}
+
+func TestCodecBitfieldsExtractInsertChannel(t *testing.T) {
+	bf := fbBitfield{Offset: 5, Length: 6}
+	for _, want := range []uint8{0x00, 0x40, 0xfc} {
+		v := insertChannel(want, bf)
+		got := extractChannel(v, bf)
+		if got != want {
+			t.Errorf("extractChannel(insertChannel(%#x)) = %#x, want %#x", want, got, want)
+		}
+	}
+}
+
+func TestCodecBitfieldsZeroLengthChannel(t *testing.T) {
+	bf := fbBitfield{Offset: 0, Length: 0}
+	if got := extractChannel(0xffffffff, bf); got != 0 {
+		t.Errorf("extractChannel with zero-length field = %#x, want 0", got)
+	}
+	if got := insertChannel(0xff, bf); got != 0 {
+		t.Errorf("insertChannel with zero-length field = %#x, want 0", got)
+	}
+}
+
+func TestSelectCodecMatchesByteOrder(t *testing.T) {
+	bgrx := fbVarScreenInfo{
+		BitsPerPixel: 32,
+		Red:          fbBitfield{Offset: 16, Length: 8},
+		Green:        fbBitfield{Offset: 8, Length: 8},
+		Blue:         fbBitfield{Offset: 0, Length: 8},
+	}
+	if _, ok := selectCodec(bgrx).(codecBGRA8888); !ok {
+		t.Errorf("selectCodec(BGRX layout) = %T, want codecBGRA8888", selectCodec(bgrx))
+	}
+
+	rgba := fbVarScreenInfo{
+		BitsPerPixel: 32,
+		Red:          fbBitfield{Offset: 0, Length: 8},
+		Green:        fbBitfield{Offset: 8, Length: 8},
+		Blue:         fbBitfield{Offset: 16, Length: 8},
+	}
+	if _, ok := selectCodec(rgba).(codecRGBA8888); !ok {
+		t.Errorf("selectCodec(RGBA layout) = %T, want codecRGBA8888", selectCodec(rgba))
+	}
+}
+
+func TestCodecBGRA8888DecodeEncode(t *testing.T) {
+	c := codecBGRA8888{}
+	px := make([]byte, c.bytesPerPixel())
+	c.encode(px, color.RGBA{R: 0x11, G: 0x22, B: 0x33, A: 0xff})
+	got := c.decode(px)
+	want := color.RGBA{R: 0x11, G: 0x22, B: 0x33, A: 0}
+	if got != want {
+		t.Errorf("BGRA8888 decode(encode(c)) = %+v, want %+v", got, want)
+	}
+}
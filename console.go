@@ -0,0 +1,133 @@
+package framebuffer
+
+import (
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+const (
+	hideCursorSeq = "\033[?25l"
+	showCursorSeq = "\033[?25h"
+
+	kdSetMode  = 0x4B3A
+	kdText     = 0
+	kdGraphics = 1
+
+	fbBlankUnblank   = 0
+	fbBlankPowerdown = 4
+)
+
+// Option configures console/VT takeover behavior for Open. None of
+// these are needed to just draw on the framebuffer; they exist because
+// drawing directly to /dev/fb0 while a getty is still active fights the
+// kernel's own console driver for control of the screen.
+type Option func(*FrameBuffer) error
+
+// consoleState tracks what Open's options changed about the console so
+// Close can put it back.
+type consoleState struct {
+	cursorTTY   *os.File
+	graphicsTTY *os.File
+	signalCh    chan os.Signal
+}
+
+// WithHideCursor hides the blinking text-console cursor for the
+// lifetime of the FrameBuffer by writing the VT100 "hide cursor"
+// sequence to /dev/tty. Close (including via SIGINT/SIGTERM) shows it
+// again.
+func WithHideCursor() Option {
+	return func(fb *FrameBuffer) error {
+		tty, err := os.OpenFile("/dev/tty", os.O_WRONLY, 0)
+		if err != nil {
+			return err
+		}
+		if _, err := tty.WriteString(hideCursorSeq); err != nil {
+			tty.Close()
+			return err
+		}
+		fb.console().cursorTTY = tty
+		return nil
+	}
+}
+
+// WithGraphicsMode switches the current virtual terminal into
+// KD_GRAPHICS mode via ioctl(KDSETMODE), so the kernel stops drawing
+// console text on top of whatever is drawn to the framebuffer. Close
+// restores KD_TEXT.
+func WithGraphicsMode() Option {
+	return func(fb *FrameBuffer) error {
+		tty, err := os.OpenFile("/dev/tty", os.O_RDWR, 0)
+		if err != nil {
+			return err
+		}
+		if err := ioctlInt(tty.Fd(), kdSetMode, kdGraphics); err != nil {
+			tty.Close()
+			return err
+		}
+		fb.console().graphicsTTY = tty
+		return nil
+	}
+}
+
+// WithBlankUnblank forces the display through a power-down/unblank
+// cycle via FBIOBLANK before handing control to the caller. Some
+// drivers are left in an inconsistent state by whatever had the
+// framebuffer open before us, and this clears it.
+func WithBlankUnblank() Option {
+	return func(fb *FrameBuffer) error {
+		fd := fb.file.Fd()
+		if err := ioctlInt(fd, fbioBlank, fbBlankPowerdown); err != nil {
+			return err
+		}
+		return ioctlInt(fd, fbioBlank, fbBlankUnblank)
+	}
+}
+
+// console lazily allocates the restore-state struct; most FrameBuffers
+// are opened without any console options and never need one.
+func (fb *FrameBuffer) console() *consoleState {
+	if fb.cons == nil {
+		fb.cons = &consoleState{}
+	}
+	return fb.cons
+}
+
+// watchSignals restores console state and exits if the process receives
+// SIGINT or SIGTERM, so a Ctrl-C doesn't leave the terminal stuck in
+// graphics mode with a hidden cursor. Ordinary panics are still the
+// caller's responsibility to handle with a deferred Close().
+func (fb *FrameBuffer) watchSignals() {
+	c := fb.console()
+	c.signalCh = make(chan os.Signal, 1)
+	signal.Notify(c.signalCh, syscall.SIGINT, syscall.SIGTERM)
+	go func() {
+		if _, ok := <-c.signalCh; !ok {
+			return
+		}
+		fb.Close()
+		os.Exit(1)
+	}()
+}
+
+// restoreConsole reverses whatever Open's Options changed, in the
+// reverse order a well-behaved cleanup should: stop watching for
+// signals first so Close doesn't race the goroutine above.
+func (fb *FrameBuffer) restoreConsole() {
+	if fb.cons == nil {
+		return
+	}
+	if fb.cons.signalCh != nil {
+		signal.Stop(fb.cons.signalCh)
+		close(fb.cons.signalCh)
+	}
+	if fb.cons.graphicsTTY != nil {
+		ioctlInt(fb.cons.graphicsTTY.Fd(), kdSetMode, kdText)
+		fb.cons.graphicsTTY.Close()
+	}
+	if fb.cons.cursorTTY != nil {
+		fb.cons.cursorTTY.WriteString(showCursorSeq)
+		fb.cons.cursorTTY.Close()
+	}
+	fb.cons = nil
+}
@@ -0,0 +1,97 @@
+package framebuffer
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+)
+
+var _ draw.Image = (*FrameBuffer)(nil)
+
+// DrawRGBA copies src into the rectangle r of the framebuffer, with
+// src's origin aligned to sp, doing a single copy() per scanline
+// instead of the per-pixel color.Color() conversions image/draw falls
+// back to for types it doesn't special-case. r is clipped to both the
+// framebuffer's and src's bounds.
+func (fb *FrameBuffer) DrawRGBA(r image.Rectangle, src *image.RGBA, sp image.Point) {
+	orig := r.Min
+	r = r.Intersect(fb.Bounds())
+	r = r.Intersect(src.Bounds().Add(orig.Sub(sp)))
+	if r.Empty() {
+		return
+	}
+	sp = sp.Add(r.Min.Sub(orig))
+
+	bpp := fb.codec.bytesPerPixel()
+	row := make([]byte, r.Dx()*bpp)
+	srcOff := sp.Sub(r.Min)
+
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		srcStart := src.PixOffset(r.Min.X+srcOff.X, y+srcOff.Y)
+		for i, x := 0, r.Min.X; x < r.Max.X; i, x = i+1, x+1 {
+			p := src.Pix[srcStart+i*4 : srcStart+i*4+4]
+			fb.codec.encode(row[i*bpp:i*bpp+bpp], color.RGBA{R: p[0], G: p[1], B: p[2], A: p[3]})
+		}
+		dstStart := fb.getPixelStart(r.Min.X, y)
+		copy(fb.buf[dstStart:dstStart+len(row)], row)
+	}
+	fb.markDirty(r.Min.X, r.Min.Y)
+	fb.markDirty(r.Max.X-1, r.Max.Y-1)
+}
+
+// DrawUniform fills the rectangle r with the single color c, encoding
+// the pixel once and then repeating it per scanline rather than calling
+// through Set for every pixel.
+func (fb *FrameBuffer) DrawUniform(r image.Rectangle, c color.RGBA) {
+	r = r.Intersect(fb.Bounds())
+	if r.Empty() {
+		return
+	}
+
+	bpp := fb.codec.bytesPerPixel()
+	px := make([]byte, bpp)
+	fb.codec.encode(px, c)
+
+	row := make([]byte, r.Dx()*bpp)
+	for i := 0; i < len(row); i += bpp {
+		copy(row[i:i+bpp], px)
+	}
+
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		dstStart := fb.getPixelStart(r.Min.X, y)
+		copy(fb.buf[dstStart:dstStart+len(row)], row)
+	}
+	fb.markDirty(r.Min.X, r.Min.Y)
+	fb.markDirty(r.Max.X-1, r.Max.Y-1)
+}
+
+// Sub returns a draw.Image view of fb restricted to r, with its own
+// Bounds() and coordinate space starting at r.Min. It shares the
+// underlying framebuffer memory, so drawing through the returned image
+// needs no bounds checking against the rest of the screen, and still
+// needs Flush() on fb (or FlushRect) to reach the display.
+func (fb *FrameBuffer) Sub(r image.Rectangle) draw.Image {
+	return &subFrameBuffer{
+		fb: fb,
+		r:  r.Intersect(fb.Bounds()),
+	}
+}
+
+type subFrameBuffer struct {
+	fb *FrameBuffer
+	r  image.Rectangle
+}
+
+func (s *subFrameBuffer) ColorModel() color.Model { return s.fb.ColorModel() }
+func (s *subFrameBuffer) Bounds() image.Rectangle { return s.r }
+
+func (s *subFrameBuffer) At(x, y int) color.Color {
+	return s.fb.At(x, y)
+}
+
+func (s *subFrameBuffer) Set(x, y int, c color.Color) {
+	if !(image.Point{X: x, Y: y}.In(s.r)) {
+		return
+	}
+	s.fb.Set(x, y, c)
+}
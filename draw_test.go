@@ -0,0 +1,57 @@
+package framebuffer
+
+import (
+	"image"
+	"image/color"
+	"testing"
+)
+
+func newTestFrameBuffer(w, h int) *FrameBuffer {
+	codec := codecRGBA8888{}
+	stride := w * codec.bytesPerPixel()
+	return &FrameBuffer{
+		buf:    make([]byte, stride*h),
+		w:      w,
+		h:      h,
+		stride: stride,
+		codec:  codec,
+	}
+}
+
+// solidRGBA returns an image where pixel (x, y) encodes its own
+// coordinates, so a shifted read is easy to detect.
+func solidRGBA(w, h int) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			img.SetRGBA(x, y, color.RGBA{R: uint8(x), G: uint8(y), B: 0, A: 0xff})
+		}
+	}
+	return img
+}
+
+func TestDrawRGBAClipNegativeMin(t *testing.T) {
+	fb := newTestFrameBuffer(100, 100)
+	src := solidRGBA(60, 50)
+
+	fb.DrawRGBA(image.Rect(-10, 0, 50, 50), src, image.Pt(0, 0))
+
+	got := fb.At(0, 0).(color.RGBA)
+	want := color.RGBA{R: 10, G: 0, B: 0, A: 0xff}
+	if got != want {
+		t.Errorf("fb.At(0,0) = %+v, want %+v (src column 10, not column 0)", got, want)
+	}
+}
+
+func TestDrawRGBANoClip(t *testing.T) {
+	fb := newTestFrameBuffer(100, 100)
+	src := solidRGBA(20, 20)
+
+	fb.DrawRGBA(image.Rect(5, 5, 25, 25), src, image.Pt(0, 0))
+
+	got := fb.At(5, 5).(color.RGBA)
+	want := color.RGBA{R: 0, G: 0, B: 0, A: 0xff}
+	if got != want {
+		t.Errorf("fb.At(5,5) = %+v, want %+v", got, want)
+	}
+}
@@ -0,0 +1,388 @@
+package framebuffer
+
+import (
+	"errors"
+	"runtime"
+	"strconv"
+	"syscall"
+	"unsafe"
+)
+
+// Minimal DRM/KMS ioctl surface, enough to claim the first connected
+// connector on a card, create one dumb buffer sized to its preferred
+// mode, and scan it out. This mirrors what ptrcnull/imagedrm does by
+// hand rather than pulling in libdrm: open the card, find a connected
+// connector+crtc, create+map a dumb buffer, modeset, and restore the
+// previous CRTC on Close so we leave the console the way we found it.
+
+const (
+	drmIoctlBase = 0x64 // 'd'
+
+	drmModeGetResources = 0xA0
+	drmModeGetConnector = 0xA7
+	drmModeGetEncoder   = 0xA6
+	drmModeGetCrtc      = 0xA1
+	drmModeSetCrtc      = 0xA2
+	drmModeCreateDumb   = 0xB2
+	drmModeMapDumb      = 0xB3
+	drmModeDestroyDumb  = 0xB4
+	drmModeAddFB        = 0xAE
+	drmModeRmFB         = 0xAF
+	drmGetCap           = 0x0C
+
+	drmModeConnected = 1
+
+	// drmCapDumbBuffer is the capability bit DRM_IOCTL_GET_CAP reports
+	// for DRM_CAP_DUMB_BUFFER: whether the driver can create dumb
+	// (CPU-mappable, no acceleration) buffers at all. Some KMS drivers
+	// (GPU-accelerated-only ones, mainly) don't support them.
+	drmCapDumbBuffer = 0x1
+)
+
+type drmModeModeInfo struct {
+	Clock                       uint32
+	HDisplay, HSyncStart        uint16
+	HSyncEnd, HTotal, HSkew     uint16
+	VDisplay, VSyncStart        uint16
+	VSyncEnd, VTotal, VScan     uint16
+	VRefresh                    uint32
+	Flags, Type                 uint32
+	Name                        [32]byte
+}
+
+type drmModeCardRes struct {
+	FbIDPtr        uint64
+	CrtcIDPtr      uint64
+	ConnectorIDPtr uint64
+	EncoderIDPtr   uint64
+	CountFbs       uint32
+	CountCrtcs     uint32
+	CountConnectors uint32
+	CountEncoders  uint32
+	MinWidth, MaxWidth   uint32
+	MinHeight, MaxHeight uint32
+}
+
+type drmModeGetConnectorReq struct {
+	EncodersPtr   uint64
+	ModesPtr      uint64
+	PropsPtr      uint64
+	PropValuesPtr uint64
+
+	CountModes     uint32
+	CountProps     uint32
+	CountEncoders  uint32
+
+	EncoderID   uint32
+	ConnectorID uint32
+	ConnectorType uint32
+	ConnectorTypeID uint32
+
+	Connection      uint32
+	MmWidth, MmHeight uint32
+	Subpixel        uint32
+
+	Pad uint32
+}
+
+type drmModeGetEncoderReq struct {
+	EncoderID   uint32
+	EncoderType uint32
+	CrtcID      uint32
+	PossibleCrtcs uint32
+	PossibleClones uint32
+}
+
+type drmModeCrtc struct {
+	SetConnectorsPtr uint64
+	CountConnectors  uint32
+
+	CrtcID uint32
+	FbID   uint32
+
+	X, Y       uint32
+	GammaSize  uint32
+	ModeValid  uint32
+	Mode       drmModeModeInfo
+}
+
+type drmModeCreateDumbReq struct {
+	Height uint32
+	Width  uint32
+	Bpp    uint32
+	Flags  uint32
+
+	Handle uint32
+	Pitch  uint32
+	Size   uint64
+}
+
+type drmModeMapDumbReq struct {
+	Handle uint32
+	Pad    uint32
+	Offset uint64
+}
+
+type drmModeAddFBReq struct {
+	Width, Height uint32
+	Pitch         uint32
+	Bpp           uint32
+	Depth         uint32
+	Handle        uint32
+	FbID          uint32
+}
+
+type drmModeDestroyDumbReq struct {
+	Handle uint32
+}
+
+type drmGetCapReq struct {
+	Capability uint64
+	Value      uint64
+}
+
+func drmIOWR(nr uint32, size uintptr) uintptr {
+	const (
+		dirWrite = 1
+		dirRead  = 2
+	)
+	dir := uintptr(dirWrite | dirRead)
+	return dir<<30 | uintptr(drmIoctlBase)<<8 | uintptr(nr) | size<<16
+}
+
+func drmIoctl(fd uintptr, nr uint32, size uintptr, arg unsafe.Pointer) error {
+	return ioctl(fd, drmIOWR(nr, size), arg)
+}
+
+// drmState holds everything OpenDRM needs to undo on Close: the CRTC we
+// overwrote (so we can put it back), and the fb/dumb-buffer handles we
+// created so we can tear them down instead of leaking kernel objects.
+type drmState struct {
+	fd          uintptr
+	connectorID uint32
+	crtcID      uint32
+	fbID        uint32
+	handle      uint32
+	savedCrtc   drmModeCrtc
+}
+
+// OpenDRM opens the DRM/KMS card at /dev/dri/card<cardIndex>, modesets
+// the first connected connector to its preferred mode using a single
+// dumb buffer, and returns it as a *FrameBuffer so existing code that
+// draws via image/draw keeps working unchanged on systems where /dev/fb0
+// isn't available (most current distros with a KMS-only GPU driver).
+func OpenDRM(cardIndex int) (*FrameBuffer, error) {
+	path := "/dev/dri/card" + strconv.Itoa(cardIndex)
+	file, err := openDevice(path)
+	if err != nil {
+		return nil, err
+	}
+	fd := file.Fd()
+
+	conn, err := findConnectedConnector(fd)
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	crtcID, err := findCrtcForConnector(fd, conn)
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	if ok, err := hasDumbBuffer(fd); err != nil {
+		file.Close()
+		return nil, err
+	} else if !ok {
+		file.Close()
+		return nil, errors.New("framebuffer: DRM driver does not support dumb buffers")
+	}
+
+	mode := conn.firstMode
+	width, height := uint32(mode.HDisplay), uint32(mode.VDisplay)
+
+	var create drmModeCreateDumbReq
+	create.Width, create.Height, create.Bpp = width, height, 32
+	if err := drmIoctl(fd, drmModeCreateDumb, unsafe.Sizeof(create), unsafe.Pointer(&create)); err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	var addFB drmModeAddFBReq
+	addFB.Width, addFB.Height = width, height
+	addFB.Pitch = create.Pitch
+	addFB.Bpp = 32
+	addFB.Depth = 24
+	addFB.Handle = create.Handle
+	if err := drmIoctl(fd, drmModeAddFB, unsafe.Sizeof(addFB), unsafe.Pointer(&addFB)); err != nil {
+		destroyDumbBuffer(fd, create.Handle)
+		file.Close()
+		return nil, err
+	}
+
+	var mapReq drmModeMapDumbReq
+	mapReq.Handle = create.Handle
+	if err := drmIoctl(fd, drmModeMapDumb, unsafe.Sizeof(mapReq), unsafe.Pointer(&mapReq)); err != nil {
+		rmFB(fd, addFB.FbID)
+		destroyDumbBuffer(fd, create.Handle)
+		file.Close()
+		return nil, err
+	}
+
+	buf, err := syscall.Mmap(int(fd), int64(mapReq.Offset), int(create.Size),
+		syscall.PROT_READ|syscall.PROT_WRITE, syscall.MAP_SHARED)
+	if err != nil {
+		rmFB(fd, addFB.FbID)
+		destroyDumbBuffer(fd, create.Handle)
+		file.Close()
+		return nil, err
+	}
+
+	var savedCrtc drmModeCrtc
+	savedCrtc.CrtcID = crtcID
+	if err := drmIoctl(fd, drmModeGetCrtc, unsafe.Sizeof(savedCrtc), unsafe.Pointer(&savedCrtc)); err != nil {
+		syscall.Munmap(buf)
+		rmFB(fd, addFB.FbID)
+		destroyDumbBuffer(fd, create.Handle)
+		file.Close()
+		return nil, err
+	}
+
+	var setCrtc drmModeCrtc
+	setCrtc.CrtcID = crtcID
+	setCrtc.FbID = addFB.FbID
+	setCrtc.ModeValid = 1
+	setCrtc.Mode = mode
+	connectorIDs := conn.connectorID
+	setCrtc.SetConnectorsPtr = uint64(uintptr(unsafe.Pointer(&connectorIDs)))
+	setCrtc.CountConnectors = 1
+	err = drmIoctl(fd, drmModeSetCrtc, unsafe.Sizeof(setCrtc), unsafe.Pointer(&setCrtc))
+	runtime.KeepAlive(connectorIDs)
+	if err != nil {
+		syscall.Munmap(buf)
+		rmFB(fd, addFB.FbID)
+		destroyDumbBuffer(fd, create.Handle)
+		file.Close()
+		return nil, err
+	}
+
+	return &FrameBuffer{
+		buf:    buf,
+		w:      int(width),
+		h:      int(height),
+		stride: int(create.Pitch),
+		codec:  codecBGRA8888{}, // dumb buffers are XRGB8888, same byte order as our BGRX assumption
+		file:   file,
+		drm: &drmState{
+			fd:          fd,
+			connectorID: conn.connectorID,
+			crtcID:      crtcID,
+			fbID:        addFB.FbID,
+			handle:      create.Handle,
+			savedCrtc:   savedCrtc,
+		},
+	}, nil
+}
+
+func rmFB(fd uintptr, fbID uint32) {
+	drmIoctl(fd, drmModeRmFB, unsafe.Sizeof(fbID), unsafe.Pointer(&fbID))
+}
+
+func destroyDumbBuffer(fd uintptr, handle uint32) {
+	req := drmModeDestroyDumbReq{Handle: handle}
+	drmIoctl(fd, drmModeDestroyDumb, unsafe.Sizeof(req), unsafe.Pointer(&req))
+}
+
+// closeDRM restores the CRTC state OpenDRM overwrote and frees the
+// kernel objects it created. Called from FrameBuffer.Close before the
+// generic munmap/file-close path.
+func (fb *FrameBuffer) closeDRM() {
+	if fb.drm == nil {
+		return
+	}
+	d := fb.drm
+	connectorIDs := d.connectorID
+	d.savedCrtc.SetConnectorsPtr = uint64(uintptr(unsafe.Pointer(&connectorIDs)))
+	d.savedCrtc.CountConnectors = 1
+	drmIoctl(d.fd, drmModeSetCrtc, unsafe.Sizeof(d.savedCrtc), unsafe.Pointer(&d.savedCrtc))
+	runtime.KeepAlive(connectorIDs)
+	rmFB(d.fd, d.fbID)
+	destroyDumbBuffer(d.fd, d.handle)
+}
+
+type connectorInfo struct {
+	connectorID uint32
+	firstMode   drmModeModeInfo
+	encoderID   uint32
+}
+
+func findConnectedConnector(fd uintptr) (connectorInfo, error) {
+	var res drmModeCardRes
+	if err := drmIoctl(fd, drmModeGetResources, unsafe.Sizeof(res), unsafe.Pointer(&res)); err != nil {
+		return connectorInfo{}, err
+	}
+	if res.CountConnectors == 0 {
+		return connectorInfo{}, errors.New("framebuffer: DRM card has no connectors")
+	}
+
+	connectorIDs := make([]uint32, res.CountConnectors)
+	res.ConnectorIDPtr = uint64(uintptr(unsafe.Pointer(&connectorIDs[0])))
+	if err := drmIoctl(fd, drmModeGetResources, unsafe.Sizeof(res), unsafe.Pointer(&res)); err != nil {
+		return connectorInfo{}, err
+	}
+
+	for _, id := range connectorIDs {
+		var conn drmModeGetConnectorReq
+		conn.ConnectorID = id
+		if err := drmIoctl(fd, drmModeGetConnector, unsafe.Sizeof(conn), unsafe.Pointer(&conn)); err != nil {
+			continue
+		}
+		if conn.Connection != drmModeConnected || conn.CountModes == 0 {
+			continue
+		}
+
+		modes := make([]drmModeModeInfo, conn.CountModes)
+		conn.ModesPtr = uint64(uintptr(unsafe.Pointer(&modes[0])))
+		if err := drmIoctl(fd, drmModeGetConnector, unsafe.Sizeof(conn), unsafe.Pointer(&conn)); err != nil {
+			continue
+		}
+
+		return connectorInfo{
+			connectorID: id,
+			firstMode:   modes[0],
+			encoderID:   conn.EncoderID,
+		}, nil
+	}
+
+	return connectorInfo{}, errors.New("framebuffer: no connected DRM connector found")
+}
+
+// hasDumbBuffer reports whether the DRM driver backing fd supports dumb
+// buffers at all, via DRM_IOCTL_GET_CAP/DRM_CAP_DUMB_BUFFER. OpenDRM
+// checks this before calling drmModeCreateDumb so a driver that doesn't
+// support them (e.g. a render-only/accel-only KMS driver) fails with a
+// clear error instead of a raw ioctl errno.
+func hasDumbBuffer(fd uintptr) (bool, error) {
+	req := drmGetCapReq{Capability: drmCapDumbBuffer}
+	if err := drmIoctl(fd, drmGetCap, unsafe.Sizeof(req), unsafe.Pointer(&req)); err != nil {
+		return false, err
+	}
+	return req.Value != 0, nil
+}
+
+func findCrtcForConnector(fd uintptr, conn connectorInfo) (uint32, error) {
+	if conn.encoderID == 0 {
+		return 0, errors.New("framebuffer: connector has no encoder")
+	}
+	var enc drmModeGetEncoderReq
+	enc.EncoderID = conn.encoderID
+	if err := drmIoctl(fd, drmModeGetEncoder, unsafe.Sizeof(enc), unsafe.Pointer(&enc)); err != nil {
+		return 0, err
+	}
+	if enc.CrtcID == 0 {
+		return 0, errors.New("framebuffer: encoder has no crtc")
+	}
+	return enc.CrtcID, nil
+}
+
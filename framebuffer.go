@@ -5,41 +5,60 @@
 // to the display.
 package framebuffer
 
-// #include "fb.h"
-// #include <stdlib.h> /* for C.free */
-import "C"
-
 import (
 	"errors"
 	"image"
 	"image/color"
 	"os"
-	"unsafe"
+	"syscall"
 )
 
 var (
 	InitErr = errors.New("Error initializing framebuffer")
 )
 
+// red/green/blue are the byte offsets of the BGRX8888 layout this
+// package originally assumed; codecBGRA8888 still uses them, but other
+// pixel formats are handled by their own codec instead.
 const (
 	red   = 2
 	green = 1
 	blue  = 0
-	x     = 3 // not sure what this does, but there's a slot for it.
-
-	colorBytes = 4
 )
 
 // A framebuffer object. Obtain with Open() - the zero value is not useful.
 // call Close() when finished to close the underlying file descriptor.
+//
+// The backing memory is mmap'd directly from the device, so At() always
+// reflects the true on-screen contents (including whatever was already
+// there, e.g. console text) and Flush() only needs to push the pixels
+// that actually changed. On a FrameBuffer from NewDoubleBuffered, At()
+// reads the back page that drawing targets, not the page on screen.
 type FrameBuffer struct {
-	buf  []byte
-	h, w int
-	file *os.File
+	buf    []byte
+	h, w   int
+	stride int
+	codec  pixelCodec
+	file   *os.File
+
+	dirty     image.Rectangle
+	haveDirty bool
+
+	// db is non-nil for a FrameBuffer obtained via NewDoubleBuffered;
+	// see doublebuffer.go.
+	db *doubleBuffer
+
+	// drm is non-nil for a FrameBuffer obtained via OpenDRM; see
+	// drm_linux.go.
+	drm *drmState
+
+	// cons is non-nil once any console-takeover Option (see console.go)
+	// has been applied.
+	cons *consoleState
 }
 
 func (fb *FrameBuffer) ColorModel() color.Model {
-	return color.RGBAModel
+	return fb.codec.colorModel()
 }
 
 func (fb *FrameBuffer) Bounds() image.Rectangle {
@@ -50,65 +69,145 @@ func (fb *FrameBuffer) Bounds() image.Rectangle {
 }
 
 func (fb *FrameBuffer) getPixelStart(x, y int) int {
-	return (y*fb.w + x) * colorBytes
+	return fb.backOffset() + y*fb.stride + x*fb.codec.bytesPerPixel()
 }
 
 func (fb *FrameBuffer) At(x, y int) color.Color {
 	pixelStart := fb.getPixelStart(x, y)
-	return color.RGBA{
-		R: fb.buf[pixelStart+red],
-		G: fb.buf[pixelStart+green],
-		B: fb.buf[pixelStart+blue],
-		A: 0,
-	}
+	bpp := fb.codec.bytesPerPixel()
+	return fb.codec.decode(fb.buf[pixelStart : pixelStart+bpp])
 }
 
 func (fb *FrameBuffer) Set(x, y int, c color.Color) {
-	pixelStart := fb.getPixelStart(x, y)
-	r, g, b, _ := c.RGBA()
-	fb.WritePixel(uint8(r), uint8(g), uint8(b))
+	r, g, b, a := c.RGBA()
+	fb.writePixel(x, y, color.RGBA{R: uint8(r >> 8), G: uint8(g >> 8), B: uint8(b >> 8), A: uint8(a >> 8)})
 }
 
 func (fb *FrameBuffer) WritePixel(x, y int, r, g, b uint8) {
-	fb.buf[pixelStart+red] = r
-	fb.buf[pixelStart+green] = g
-	fb.buf[pixelStart+blue] = b
+	fb.writePixel(x, y, color.RGBA{R: r, G: g, B: b, A: 0xff})
+}
+
+func (fb *FrameBuffer) writePixel(x, y int, c color.RGBA) {
+	pixelStart := fb.getPixelStart(x, y)
+	bpp := fb.codec.bytesPerPixel()
+	fb.codec.encode(fb.buf[pixelStart:pixelStart+bpp], c)
+	fb.markDirty(x, y)
+}
+
+// markDirty grows the pending dirty rectangle to include (x, y). Flush
+// uses this to avoid msync'ing/writing the whole buffer when only a
+// small part of the screen changed.
+func (fb *FrameBuffer) markDirty(x, y int) {
+	p := image.Pt(x, y)
+	if !fb.haveDirty {
+		fb.dirty = image.Rectangle{Min: p, Max: p.Add(image.Pt(1, 1))}
+		fb.haveDirty = true
+		return
+	}
+	fb.dirty = fb.dirty.Union(image.Rectangle{Min: p, Max: p.Add(image.Pt(1, 1))})
 }
 
 // Sync changes to video memory - nothing will actually appear on the
-// screen until this is called.
+// screen until this is called. Only the region touched by Set,
+// WritePixel, DrawRGBA or DrawUniform since the last Flush is actually
+// synced; call FlushRect directly to sync an explicit rectangle.
 func (fb *FrameBuffer) Flush() error {
-	fb.file.Seek(0, 0)
-	_, err := fb.file.Write(fb.buf)
-	return err
+	if !fb.haveDirty {
+		return nil
+	}
+	r := fb.dirty
+	fb.dirty = image.Rectangle{}
+	fb.haveDirty = false
+	return fb.FlushRect(r)
+}
+
+// FlushRect syncs only the scanlines covered by r to the display. It is
+// the primitive Flush() is built on, and is useful to call directly when
+// the caller knows exactly what changed (e.g. a partial redraw) without
+// going through Set/WritePixel's dirty tracking.
+func (fb *FrameBuffer) FlushRect(r image.Rectangle) error {
+	r = r.Intersect(fb.Bounds())
+	if r.Empty() {
+		return nil
+	}
+
+	start := fb.getPixelStart(r.Min.X, r.Min.Y)
+	end := fb.getPixelStart(r.Min.X, r.Max.Y-1) + fb.stride
+
+	return fb.msync(start, end-start)
 }
 
 // Closes the framebuffer
 func (fb *FrameBuffer) Close() error {
-	return fb.file.Close()
+	fb.restoreConsole()
+	fb.closeDRM()
+	err := syscall.Munmap(fb.buf)
+	if cerr := fb.file.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// openDevice opens the framebuffer device node, wrapping the OS error in
+// InitErr the way the rest of this package's constructors do.
+func openDevice(filename string) (*os.File, error) {
+	file, err := os.OpenFile(filename, os.O_RDWR, 0)
+	if err != nil {
+		return nil, InitErr
+	}
+	return file, nil
 }
 
-// Opens/initializes the framebuffer with device node located at <filename>.
-func Open(filename string) (*FrameBuffer, error) {
-	var cFilename *C.char
-	cFilename = C.CString(filename)
-	defer C.free(unsafe.Pointer(cFilename))
-	var info C.fb_info_t
-	cErr := C.initfb(cFilename, &info)
-	if cErr != 0 {
+// Opens/initializes the framebuffer with device node located at
+// <filename>. Pass WithHideCursor, WithGraphicsMode and/or
+// WithBlankUnblank to additionally take over the console for the
+// lifetime of the FrameBuffer; see their doc comments for what each one
+// does and reverses on Close.
+func Open(filename string, opts ...Option) (*FrameBuffer, error) {
+	file, err := openDevice(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	fd := file.Fd()
+
+	varInfo, err := getVarScreenInfo(fd)
+	if err != nil {
+		file.Close()
 		return nil, InitErr
 	}
 
-	return &FrameBuffer{
-		buf: make([]byte, info.fix_info.smem_len),
-		// XXX: this is theoretically problematic; xres/yres are
-		// uint32, so if we're dealing with a *huge* display, this
-		// could overflow. image.Point expects int though, so we're
-		// kinda stuck. fortunately displays that are greater than 2
-		// million pixels in one dimension don't exist, and probably
-		// never will unless we decide we need a retina display the
-		// size of a football field or something.
-		w: int(info.var_info.xres),
-		h: int(info.var_info.yres),
-		file: os.NewFile(uintptr(info.fd), filename)}, nil
+	fixInfo, err := getFixScreenInfo(fd)
+	if err != nil {
+		file.Close()
+		return nil, InitErr
+	}
+
+	buf, err := syscall.Mmap(int(fd), 0, int(fixInfo.SmemLen),
+		syscall.PROT_READ|syscall.PROT_WRITE, syscall.MAP_SHARED)
+	if err != nil {
+		file.Close()
+		return nil, InitErr
+	}
+
+	fb := &FrameBuffer{
+		buf:    buf,
+		w:      int(varInfo.XRes),
+		h:      int(varInfo.YRes),
+		stride: int(fixInfo.LineLength),
+		codec:  selectCodec(varInfo),
+		file:   file,
+	}
+
+	for _, opt := range opts {
+		if err := opt(fb); err != nil {
+			fb.Close()
+			return nil, err
+		}
+	}
+	if fb.cons != nil {
+		fb.watchSignals()
+	}
+
+	return fb, nil
 }
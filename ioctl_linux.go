@@ -0,0 +1,116 @@
+package framebuffer
+
+import (
+	"syscall"
+	"unsafe"
+)
+
+// ioctl request numbers from linux/fb.h. Kept here rather than imported
+// from cgo so the package has no C toolchain dependency.
+const (
+	fbioGetVScreenInfo = 0x4600
+	fbioPutVScreenInfo = 0x4601
+	fbioGetFScreenInfo = 0x4602
+	fbioPanDisplay     = 0x4606
+	fbioBlank          = 0x4611
+	fbioWaitForVSync   = 0x40044620 // _IOW('F', 0x20, __u32)
+)
+
+// fbBitfield mirrors struct fb_bitfield.
+type fbBitfield struct {
+	Offset   uint32
+	Length   uint32
+	MSBRight uint32
+}
+
+// fbVarScreenInfo mirrors struct fb_var_screeninfo.
+type fbVarScreenInfo struct {
+	XRes, YRes               uint32
+	XResVirtual, YResVirtual uint32
+	XOffset, YOffset         uint32
+
+	BitsPerPixel uint32
+	Grayscale    uint32
+
+	Red, Green, Blue, Transp fbBitfield
+
+	NonStd uint32
+
+	Activate uint32
+
+	Height uint32
+	Width  uint32
+
+	AccelFlags uint32
+
+	PixClock                 uint32
+	LeftMargin, RightMargin  uint32
+	UpperMargin, LowerMargin uint32
+	HSyncLen, VSyncLen       uint32
+	Sync, VMode              uint32
+	Rotate                   uint32
+	Colorspace               uint32
+	Reserved                 [4]uint32
+}
+
+// fbFixScreenInfo mirrors struct fb_fix_screeninfo.
+type fbFixScreenInfo struct {
+	ID           [16]byte
+	SmemStart    uint64
+	SmemLen      uint32
+	Type         uint32
+	TypeAux      uint32
+	Visual       uint32
+	XPanStep     uint16
+	YPanStep     uint16
+	YWrapStep    uint16
+	LineLength   uint32
+	MmioStart    uint64
+	MmioLen      uint32
+	Accel        uint32
+	Capabilities uint16
+	Reserved     [2]uint16
+}
+
+func ioctl(fd uintptr, req uintptr, arg unsafe.Pointer) error {
+	_, _, errno := syscall.Syscall(syscall.SYS_IOCTL, fd, req, uintptr(arg))
+	if errno != 0 {
+		return errno
+	}
+	return nil
+}
+
+// ioctlInt issues an ioctl whose third argument is an integer value
+// rather than a pointer, as used by e.g. KDSETMODE and FBIOBLANK.
+func ioctlInt(fd uintptr, req uintptr, val uintptr) error {
+	_, _, errno := syscall.Syscall(syscall.SYS_IOCTL, fd, req, val)
+	if errno != 0 {
+		return errno
+	}
+	return nil
+}
+
+func getVarScreenInfo(fd uintptr) (fbVarScreenInfo, error) {
+	var info fbVarScreenInfo
+	err := ioctl(fd, fbioGetVScreenInfo, unsafe.Pointer(&info))
+	return info, err
+}
+
+func getFixScreenInfo(fd uintptr) (fbFixScreenInfo, error) {
+	var info fbFixScreenInfo
+	err := ioctl(fd, fbioGetFScreenInfo, unsafe.Pointer(&info))
+	return info, err
+}
+
+func putVarScreenInfo(fd uintptr, info *fbVarScreenInfo) error {
+	return ioctl(fd, fbioPutVScreenInfo, unsafe.Pointer(info))
+}
+
+func panDisplay(fd uintptr, info *fbVarScreenInfo) error {
+	return ioctl(fd, fbioPanDisplay, unsafe.Pointer(info))
+}
+
+func waitForVSync(fd uintptr) error {
+	var crtc uint32
+	return ioctl(fd, fbioWaitForVSync, unsafe.Pointer(&crtc))
+}
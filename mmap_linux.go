@@ -0,0 +1,25 @@
+package framebuffer
+
+import (
+	"syscall"
+	"unsafe"
+)
+
+const msSync = 0x4 // MS_SYNC
+
+// msync flushes the mmap'd region [offset, offset+length) to the
+// underlying device, without requiring the whole buffer to be written.
+// offset is rounded down to a page boundary, since msync only accepts
+// page-aligned addresses.
+func (fb *FrameBuffer) msync(offset, length int) error {
+	pageSize := syscall.Getpagesize()
+	aligned := offset - offset%pageSize
+	length += offset - aligned
+
+	base := uintptr(unsafe.Pointer(&fb.buf[0])) + uintptr(aligned)
+	_, _, errno := syscall.Syscall(syscall.SYS_MSYNC, base, uintptr(length), msSync)
+	if errno != 0 {
+		return errno
+	}
+	return nil
+}
@@ -0,0 +1,53 @@
+package framebuffer
+
+import "image"
+
+// Snapshot reads the current on-screen contents and returns them as a
+// standard *image.RGBA, regardless of the device's native pixel format
+// (RGB565, BGRA8888, 24bpp RGB, ...). Useful for e.g. encoding a
+// screenshot with image/png without reimplementing the codec's
+// byte-order swizzling at the call site.
+func (fb *FrameBuffer) Snapshot() (*image.RGBA, error) {
+	img := image.NewRGBA(fb.Bounds())
+	if err := fb.readPixelsInto(img.Pix, img.Stride); err != nil {
+		return nil, err
+	}
+	return img, nil
+}
+
+// ReadPixels reads the current on-screen contents into a caller-supplied
+// buffer, already converted to RGBA8888 with no padding between rows
+// (stride == Bounds().Dx()*4). len(dst) must be at least
+// Bounds().Dx()*Bounds().Dy()*4.
+func (fb *FrameBuffer) ReadPixels(dst []byte) error {
+	return fb.readPixelsInto(dst, fb.w*4)
+}
+
+// readPixelsInto decodes every on-screen pixel through fb.codec and
+// re-encodes it as RGBA8888 into dst, one scanline at a time. Formats
+// that don't carry a real alpha channel (i.e. everything but
+// codecRGBA8888) decode with A: 0, which would otherwise make the
+// result fully transparent; force those opaque instead.
+func (fb *FrameBuffer) readPixelsInto(dst []byte, dstStride int) error {
+	bpp := fb.codec.bytesPerPixel()
+	front := fb.frontOffset()
+	opaque := !fb.codec.hasAlpha()
+
+	for y := 0; y < fb.h; y++ {
+		srcStart := front + y*fb.stride
+		dstStart := y * dstStride
+		for x := 0; x < fb.w; x++ {
+			px := fb.buf[srcStart+x*bpp : srcStart+x*bpp+bpp]
+			c := fb.codec.decode(px)
+			if opaque {
+				c.A = 0xff
+			}
+			o := dstStart + x*4
+			dst[o] = c.R
+			dst[o+1] = c.G
+			dst[o+2] = c.B
+			dst[o+3] = c.A
+		}
+	}
+	return nil
+}
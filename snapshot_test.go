@@ -0,0 +1,57 @@
+package framebuffer
+
+import (
+	"image/color"
+	"testing"
+)
+
+func TestSnapshotForcesOpaqueAlphaForNonAlphaCodec(t *testing.T) {
+	codec := codecBGRA8888{}
+	stride := 4 * codec.bytesPerPixel()
+	fb := &FrameBuffer{
+		buf:    make([]byte, stride*4),
+		w:      4,
+		h:      4,
+		stride: stride,
+		codec:  codec,
+	}
+
+	fb.WritePixel(1, 1, 200, 100, 50)
+
+	img, err := fb.Snapshot()
+	if err != nil {
+		t.Fatalf("Snapshot() error = %v", err)
+	}
+
+	got := img.RGBAAt(1, 1)
+	want := color.RGBA{R: 200, G: 100, B: 50, A: 0xff}
+	if got != want {
+		t.Errorf("Snapshot().RGBAAt(1,1) = %+v, want %+v", got, want)
+	}
+}
+
+func TestSnapshotPreservesRealAlpha(t *testing.T) {
+	codec := codecRGBA8888{}
+	stride := 4 * codec.bytesPerPixel()
+	fb := &FrameBuffer{
+		buf:    make([]byte, stride*4),
+		w:      4,
+		h:      4,
+		stride: stride,
+		codec:  codec,
+	}
+
+	px := fb.buf[fb.getPixelStart(1, 1):]
+	codec.encode(px[:4], color.RGBA{R: 200, G: 100, B: 50, A: 0x7f})
+
+	img, err := fb.Snapshot()
+	if err != nil {
+		t.Fatalf("Snapshot() error = %v", err)
+	}
+
+	got := img.RGBAAt(1, 1)
+	want := color.RGBA{R: 200, G: 100, B: 50, A: 0x7f}
+	if got != want {
+		t.Errorf("Snapshot().RGBAAt(1,1) = %+v, want %+v", got, want)
+	}
+}